@@ -0,0 +1,183 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("credential-builder")
+
+const (
+	GCSCredentialVolumeName      = "user-gcp-sa"
+	GCSCredentialFileName        = "gcloud-application-credentials.json"
+	GCSCredentialVolumeMountPath = "/var/secrets/cloud.google.com"
+	GCSCredentialEnvKey          = "GOOGLE_APPLICATION_CREDENTIALS"
+
+	S3AccessKeyIDName     = "AWS_ACCESS_KEY_ID"
+	S3SecretAccessKeyName = "AWS_SECRET_ACCESS_KEY"
+	S3EndpointEnvKey      = "AWS_ENDPOINT_URL"
+
+	// S3SecretEndpointAnnotation marks a Secret as holding S3 credentials and gives the
+	// endpoint the model-initializer should talk to.
+	S3SecretEndpointAnnotation = "serving.seldon.io/s3-endpoint"
+
+	// CredentialScopingConfigMapKey selects the authorization mode CreateSecretVolumeAndEnv
+	// runs under. "strict" ignores a requested ServiceAccount override that doesn't match the
+	// Pod's own ServiceAccountName, and additionally requires AllowModelInitAnnotationKey on the
+	// secret; "legacy" (the default) honors any requested ServiceAccount and mounts any secret
+	// it references, as seldon-operator has always done.
+	CredentialScopingConfigMapKey = "credentialScoping"
+	CredentialScopingStrict       = "strict"
+	CredentialScopingLegacy       = "legacy"
+
+	// AllowModelInitAnnotationKey must be set to "true" on a Secret for it to be mountable into
+	// a model-initializer init container while running under CredentialScopingStrict.
+	AllowModelInitAnnotationKey = "seldon.io/allow-model-init"
+)
+
+// CredentialBuilder projects the credentials referenced by a ServiceAccount into the
+// model-initializer init container, either as env vars (S3) or a mounted volume (GCS).
+type CredentialBuilder struct {
+	client  client.Client
+	scoping string
+}
+
+// NewCredentialBulder builds a CredentialBuilder from the seldon-config ConfigMap. The
+// "credentialScoping" key selects strict or legacy authorization; it defaults to legacy so
+// existing installs keep working until they opt in.
+func NewCredentialBulder(client client.Client, config *corev1.ConfigMap) *CredentialBuilder {
+	scoping := config.Data[CredentialScopingConfigMapKey]
+	if scoping != CredentialScopingStrict {
+		scoping = CredentialScopingLegacy
+	}
+
+	return &CredentialBuilder{
+		client:  client,
+		scoping: scoping,
+	}
+}
+
+// CreateSecretVolumeAndEnv inspects the secrets referenced by serviceAccountName and projects
+// any it recognises (S3 or GCS) into container and volumes. serviceAccountName may come from a
+// Pod annotation and so can name any ServiceAccount in namespace; podServiceAccountName is the
+// ServiceAccount actually assigned to the Pod's spec (podSpec.ServiceAccountName). Under
+// CredentialScopingStrict, a serviceAccountName that doesn't match podServiceAccountName is
+// ignored in favour of podServiceAccountName - otherwise an annotation could point at an
+// unrelated ServiceAccount in the namespace purely to pull in its secrets - and any secret it
+// does end up inspecting must additionally carry AllowModelInitAnnotationKey. Under the legacy
+// (default) behaviour neither check applies, matching what seldon-operator has always done.
+func (c *CredentialBuilder) CreateSecretVolumeAndEnv(namespace string, serviceAccountName string, podServiceAccountName string, container *corev1.Container, volumes *[]corev1.Volume) error {
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	if podServiceAccountName == "" {
+		podServiceAccountName = "default"
+	}
+
+	if c.scoping == CredentialScopingStrict && serviceAccountName != podServiceAccountName {
+		log.Info("ignoring service account override under strict credential scoping", "namespace", namespace, "requested", serviceAccountName, "pod", podServiceAccountName)
+		serviceAccountName = podServiceAccountName
+	}
+
+	serviceAccount := &corev1.ServiceAccount{}
+	err := c.client.Get(context.TODO(), types.NamespacedName{Name: serviceAccountName, Namespace: namespace}, serviceAccount)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, secretRef := range serviceAccount.Secrets {
+		secret := &corev1.Secret{}
+		if err := c.client.Get(context.TODO(), types.NamespacedName{Name: secretRef.Name, Namespace: namespace}, secret); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if c.scoping == CredentialScopingStrict && secret.Annotations[AllowModelInitAnnotationKey] != "true" {
+			log.Info("Skipping secret not annotated for model provisioning", "namespace", namespace, "secret", secret.Name)
+			continue
+		}
+
+		if endpoint, ok := secret.Annotations[S3SecretEndpointAnnotation]; ok {
+			applyS3Credentials(container, secret, endpoint)
+			continue
+		}
+
+		if _, ok := secret.Data[GCSCredentialFileName]; ok {
+			applyGCSCredentials(container, volumes, secret)
+			continue
+		}
+	}
+
+	return nil
+}
+
+func applyS3Credentials(container *corev1.Container, secret *corev1.Secret, endpoint string) {
+	container.Env = append(container.Env,
+		corev1.EnvVar{
+			Name: S3AccessKeyIDName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  "AWS_ACCESS_KEY_ID",
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name: S3SecretAccessKeyName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  "AWS_SECRET_ACCESS_KEY",
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name:  S3EndpointEnvKey,
+			Value: endpoint,
+		},
+	)
+}
+
+func applyGCSCredentials(container *corev1.Container, volumes *[]corev1.Volume, secret *corev1.Secret) {
+	*volumes = append(*volumes, corev1.Volume{
+		Name: GCSCredentialVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secret.Name},
+		},
+	})
+
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      GCSCredentialVolumeName,
+		MountPath: GCSCredentialVolumeMountPath,
+		ReadOnly:  true,
+	})
+
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  GCSCredentialEnvKey,
+		Value: fmt.Sprintf("%s/%s", GCSCredentialVolumeMountPath, GCSCredentialFileName),
+	})
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	return scheme
+}
+
+func TestCreateSecretVolumeAndEnvLegacyMountsUnannotatedSecret(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		Secrets:    []corev1.ObjectReference{{Name: "s3-creds"}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "s3-creds",
+			Namespace:   "ns",
+			Annotations: map[string]string{S3SecretEndpointAnnotation: "s3.amazonaws.com"},
+		},
+	}
+	cl := fake.NewFakeClientWithScheme(newTestScheme(), serviceAccount, secret)
+	builder := NewCredentialBulder(cl, &corev1.ConfigMap{})
+
+	container := &corev1.Container{}
+	volumes := []corev1.Volume{}
+	if err := builder.CreateSecretVolumeAndEnv("ns", "default", "default", container, &volumes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(container.Env) != 3 {
+		t.Fatalf("expected 3 S3 env vars to be set under legacy scoping, got %d", len(container.Env))
+	}
+}
+
+func TestCreateSecretVolumeAndEnvStrictRejectsUnannotatedSecret(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		Secrets:    []corev1.ObjectReference{{Name: "s3-creds"}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "s3-creds",
+			Namespace:   "ns",
+			Annotations: map[string]string{S3SecretEndpointAnnotation: "s3.amazonaws.com"},
+		},
+	}
+	cl := fake.NewFakeClientWithScheme(newTestScheme(), serviceAccount, secret)
+	builder := NewCredentialBulder(cl, &corev1.ConfigMap{Data: map[string]string{CredentialScopingConfigMapKey: CredentialScopingStrict}})
+
+	container := &corev1.Container{}
+	volumes := []corev1.Volume{}
+	if err := builder.CreateSecretVolumeAndEnv("ns", "default", "default", container, &volumes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(container.Env) != 0 {
+		t.Fatalf("expected the secret to be rejected for missing %s annotation, got env %v", AllowModelInitAnnotationKey, container.Env)
+	}
+}
+
+func TestCreateSecretVolumeAndEnvStrictMountsAllowedSecret(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		Secrets:    []corev1.ObjectReference{{Name: "s3-creds"}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s3-creds",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				S3SecretEndpointAnnotation:  "s3.amazonaws.com",
+				AllowModelInitAnnotationKey: "true",
+			},
+		},
+	}
+	cl := fake.NewFakeClientWithScheme(newTestScheme(), serviceAccount, secret)
+	builder := NewCredentialBulder(cl, &corev1.ConfigMap{Data: map[string]string{CredentialScopingConfigMapKey: CredentialScopingStrict}})
+
+	container := &corev1.Container{}
+	volumes := []corev1.Volume{}
+	if err := builder.CreateSecretVolumeAndEnv("ns", "default", "default", container, &volumes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(container.Env) != 3 {
+		t.Fatalf("expected the allowed secret to be mounted, got env %v", container.Env)
+	}
+}
+
+func TestCreateSecretVolumeAndEnvStrictIgnoresMismatchedServiceAccountOverride(t *testing.T) {
+	podServiceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		Secrets:    []corev1.ObjectReference{{Name: "s3-creds"}},
+	}
+	otherServiceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-sa", Namespace: "ns"},
+		Secrets:    []corev1.ObjectReference{{Name: "other-creds"}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s3-creds",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				S3SecretEndpointAnnotation:  "s3.amazonaws.com",
+				AllowModelInitAnnotationKey: "true",
+			},
+		},
+	}
+	otherSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-creds",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				S3SecretEndpointAnnotation:  "other.example.com",
+				AllowModelInitAnnotationKey: "true",
+			},
+		},
+	}
+	cl := fake.NewFakeClientWithScheme(newTestScheme(), podServiceAccount, otherServiceAccount, secret, otherSecret)
+	builder := NewCredentialBulder(cl, &corev1.ConfigMap{Data: map[string]string{CredentialScopingConfigMapKey: CredentialScopingStrict}})
+
+	container := &corev1.Container{}
+	volumes := []corev1.Volume{}
+	// serviceAccountName ("other-sa") is an annotation-supplied override that doesn't match
+	// podServiceAccountName ("default"); under strict scoping it must be ignored in favour of
+	// the Pod's actual ServiceAccount rather than trusted.
+	if err := builder.CreateSecretVolumeAndEnv("ns", "other-sa", "default", container, &volumes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, env := range container.Env {
+		if env.Name == S3EndpointEnvKey && env.Value == "other.example.com" {
+			t.Fatalf("expected the mismatched service account override to be ignored, got env %v", container.Env)
+		}
+	}
+	if len(container.Env) != 3 {
+		t.Fatalf("expected the pod's own service account's secret to be mounted instead, got env %v", container.Env)
+	}
+}
+
+func TestCreateSecretVolumeAndEnvStrictRejectsSecretNotOnServiceAccount(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "s3-creds",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				S3SecretEndpointAnnotation:  "s3.amazonaws.com",
+				AllowModelInitAnnotationKey: "true",
+			},
+		},
+	}
+	cl := fake.NewFakeClientWithScheme(newTestScheme(), serviceAccount, secret)
+	builder := NewCredentialBulder(cl, &corev1.ConfigMap{Data: map[string]string{CredentialScopingConfigMapKey: CredentialScopingStrict}})
+
+	container := &corev1.Container{}
+	volumes := []corev1.Volume{}
+	if err := builder.CreateSecretVolumeAndEnv("ns", "default", "default", container, &volumes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(container.Env) != 0 {
+		t.Fatalf("expected the secret to be rejected as unreferenced by the service account, got env %v", container.Env)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/seldonio/seldon-operator/pkg/controller/resources/credentials"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("pod-mutator-webhook")
+
+// TODO: change to seldon
+const (
+	DefaultModelLocalMountPath       = "/mnt/models"
+	ModelInitializerContainerName    = "model-initializer"
+	ModelInitializerVolumeName       = "kfserving-provision-location"
+	ModelInitializerContainerImage   = "gcr.io/kfserving/model-initializer"
+	ModelInitializerContainerVersion = "latest"
+	PvcURIPrefix                     = "pvc://"
+	PvcSourceMountName               = "kfserving-pvc-source"
+	PvcSourceMountPath               = "/mnt/pvc"
+	UserContainerName                = "user-container"
+
+	// StorageInitializerSourceUriInternalAnnotationKey is read from the Pod by the
+	// mutating webhook to discover where the model artefacts for the user
+	// container should be provisioned from.
+	StorageInitializerSourceUriInternalAnnotationKey = "serving.seldon.io/storage-uri"
+	// StorageInitializerServiceAccountAnnotationKey optionally overrides the
+	// ServiceAccount used to look up provisioning credentials, in case it
+	// differs from the Pod's own ServiceAccountName.
+	StorageInitializerServiceAccountAnnotationKey = "serving.seldon.io/service-account"
+	// StorageDirectMountAnnotationKey opts a pvc:// source URI into mounting the PVC directly
+	// into the user container instead of provisioning through the model-initializer init
+	// container and an emptyDir.
+	StorageDirectMountAnnotationKey = "seldon.io/storage-direct-mount"
+	// StorageReadonlyAnnotationKey controls whether a direct PVC mount is read-only. Defaults
+	// to true; set to "false" to allow writable model caches / online learning.
+	StorageReadonlyAnnotationKey = "seldon.io/storage-readonly"
+)
+
+var (
+	ControllerNamespace     = getEnv("POD_NAMESPACE", "seldon-system")
+	ControllerConfigMapName = "seldon-config"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getControllerConfigMap(Client client.Client) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := Client.Get(context.TODO(), types.NamespacedName{Name: ControllerConfigMapName, Namespace: ControllerNamespace}, configMap); err != nil {
+		log.Error(err, "Failed to find config map", "name", ControllerConfigMapName)
+		return nil, err
+	}
+	return configMap, nil
+}
+
+func credentialsBuilder(configMap *corev1.ConfigMap, Client client.Client) *credentials.CredentialBuilder {
+	return credentials.NewCredentialBulder(Client, configMap)
+}
+
+// InjectorOptions controls optional behaviour of InjectModelInitializer beyond the default of
+// provisioning srcURI into a shared emptyDir via the model-initializer init container.
+type InjectorOptions struct {
+	// DirectVolumeMount, when true and srcURI is a pvc:// URI, mounts the PVC straight into the
+	// user container (via SubPath, if a path is given) instead of provisioning through the
+	// model-initializer init container. This avoids the copy step, lets multiple pods share a
+	// ReadOnlyMany PVC, and supports models too large to fit in an emptyDir.
+	DirectVolumeMount bool
+	// ReadOnly controls whether a direct PVC mount is read-only. Defaults to true; set to false
+	// for writable model caches / online learning. Ignored unless DirectVolumeMount is set.
+	ReadOnly bool
+}
+
+// InjectModelInitializer injects an init container to provision model data into the given PodSpec.
+// It is invoked by the Pod mutating webhook rather than the SeldonDeployment controller, so that
+// any Pod-producing controller (Deployment, Job, StatefulSet, Argo Workflow, ...) picks up model
+// provisioning without the operator needing to build the Pod itself.
+func InjectModelInitializer(podSpec *corev1.PodSpec, userContainer *corev1.Container, srcURI string, serviceAccountName string, namespace string, Client client.Client, options *InjectorOptions) error {
+
+	// Dont inject if InitContianer already injected
+	for _, container := range podSpec.InitContainers {
+		if strings.Compare(container.Name, ModelInitializerContainerName) == 0 {
+			return nil
+		}
+	}
+
+	if options != nil && options.DirectVolumeMount && strings.HasPrefix(srcURI, PvcURIPrefix) {
+		return injectDirectPvcMount(podSpec, userContainer, srcURI, options.ReadOnly)
+	}
+
+	configMap, err := getControllerConfigMap(Client)
+	if err != nil {
+		return err
+	}
+
+	registry, err := provisionerRegistryFromConfigMap(configMap)
+	if err != nil {
+		return err
+	}
+	provisioner := registry.lookup(srcURI)
+
+	podVolumes := []corev1.Volume{}
+	modelInitializerMounts := []corev1.VolumeMount{}
+
+	// For PVC source URIs we need to mount the source to be able to access it
+	// See design and discussion here: https://github.com/kubeflow/kfserving/issues/148
+	if strings.HasPrefix(srcURI, PvcURIPrefix) {
+		pvcName, pvcPath, err := parsePvcURI(srcURI)
+		if err != nil {
+			return err
+		}
+
+		// add the PVC volume on the pod
+		pvcSourceVolume := corev1.Volume{
+			Name: PvcSourceMountName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcName,
+				},
+			},
+		}
+		podVolumes = append(podVolumes, pvcSourceVolume)
+
+		// add a corresponding PVC volume mount to the INIT container
+		pvcSourceVolumeMount := corev1.VolumeMount{
+			Name:      PvcSourceMountName,
+			MountPath: PvcSourceMountPath,
+			ReadOnly:  true,
+		}
+		modelInitializerMounts = append(modelInitializerMounts, pvcSourceVolumeMount)
+
+		// modify the sourceURI to point to the PVC path
+		srcURI = PvcSourceMountPath + "/" + pvcPath
+	}
+
+	// Create a volume that is shared between the model-initializer and user-container
+	sharedVolume := corev1.Volume{
+		Name: ModelInitializerVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+	podVolumes = append(podVolumes, sharedVolume)
+
+	// Create a write mount into the shared volume
+	sharedVolumeWriteMount := corev1.VolumeMount{
+		Name:      ModelInitializerVolumeName,
+		MountPath: DefaultModelLocalMountPath,
+		ReadOnly:  false,
+	}
+	modelInitializerMounts = append(modelInitializerMounts, sharedVolumeWriteMount)
+
+	// Add an init container to run provisioning logic to the PodSpec. The image, resources, env
+	// and any extra args come from the Provisioner registered for srcURI's scheme, falling back
+	// to the default model-initializer image if the scheme isn't registered.
+	initContainer := &corev1.Container{
+		Name:  ModelInitializerContainerName,
+		Image: provisioner.Image,
+		Args: append([]string{
+			srcURI,
+			DefaultModelLocalMountPath,
+		}, provisioner.Args...),
+		Resources:    provisioner.Resources,
+		Env:          provisioner.Env,
+		VolumeMounts: modelInitializerMounts,
+	}
+
+	// Add a mount the shared volume on the user-container, update the PodSpec
+	sharedVolumeReadMount := corev1.VolumeMount{
+		Name:      ModelInitializerVolumeName,
+		MountPath: DefaultModelLocalMountPath,
+		ReadOnly:  true,
+	}
+	userContainer.VolumeMounts = append(userContainer.VolumeMounts, sharedVolumeReadMount)
+
+	// Add volumes to the PodSpec
+	podSpec.Volumes = append(podSpec.Volumes, podVolumes...)
+
+	// Inject credentials
+	credentialsBuilder := credentialsBuilder(configMap, Client)
+	if serviceAccountName == "" {
+		serviceAccountName = podSpec.ServiceAccountName
+	}
+
+	if err := credentialsBuilder.CreateSecretVolumeAndEnv(
+		namespace,
+		serviceAccountName,
+		podSpec.ServiceAccountName,
+		initContainer,
+		&podSpec.Volumes,
+	); err != nil {
+		return err
+	}
+
+	// Add init container to the spec
+	podSpec.InitContainers = append(podSpec.InitContainers, *initContainer)
+
+	return nil
+}
+
+// injectDirectPvcMount mounts the PVC named in srcURI directly into the user container at
+// DefaultModelLocalMountPath, using a SubPath if srcURI names a path within the PVC. Unlike the
+// default mode it does not add a model-initializer init container or a shared emptyDir: the user
+// container reads the model straight off the PVC.
+func injectDirectPvcMount(podSpec *corev1.PodSpec, userContainer *corev1.Container, srcURI string, readOnly bool) error {
+	pvcName, pvcPath, err := parsePvcURI(srcURI)
+	if err != nil {
+		return err
+	}
+
+	directVolume := corev1.Volume{
+		Name: ModelInitializerVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pvcName,
+				ReadOnly:  readOnly,
+			},
+		},
+	}
+	podSpec.Volumes = append(podSpec.Volumes, directVolume)
+
+	directMount := corev1.VolumeMount{
+		Name:      ModelInitializerVolumeName,
+		MountPath: DefaultModelLocalMountPath,
+		ReadOnly:  readOnly,
+		SubPath:   pvcPath,
+	}
+	userContainer.VolumeMounts = append(userContainer.VolumeMounts, directMount)
+
+	return nil
+}
+
+func parsePvcURI(srcURI string) (pvcName string, pvcPath string, err error) {
+	parts := strings.Split(strings.TrimPrefix(srcURI, PvcURIPrefix), "/")
+	if len(parts) > 1 {
+		pvcName = parts[0]
+		pvcPath = strings.Join(parts[1:], "/")
+	} else if len(parts) == 1 {
+		pvcName = parts[0]
+		pvcPath = ""
+	} else {
+		return "", "", fmt.Errorf("Invalid URI must be pvc://<pvcname>/[path]: %s", srcURI)
+	}
+
+	return pvcName, pvcPath, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func fakeConfigMapClient() client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ControllerConfigMapName, Namespace: ControllerNamespace},
+		Data:       map[string]string{},
+	}
+	return fake.NewFakeClientWithScheme(scheme, configMap)
+}
+
+func testPodSpec() *corev1.PodSpec {
+	return &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: UserContainerName},
+		},
+	}
+}
+
+func TestInjectModelInitializerPvc(t *testing.T) {
+	podSpec := testPodSpec()
+	userContainer := &podSpec.Containers[0]
+
+	if err := InjectModelInitializer(podSpec, userContainer, "pvc://my-pvc/models/my-model", "", "default", fakeConfigMapClient(), nil); err != nil {
+		t.Fatalf("unexpected error injecting model-initializer for PVC source: %v", err)
+	}
+
+	assertModelInitializerInjected(t, podSpec, userContainer)
+
+	found := false
+	for _, v := range podSpec.Volumes {
+		if v.Name == PvcSourceMountName && v.VolumeSource.PersistentVolumeClaim != nil && v.VolumeSource.PersistentVolumeClaim.ClaimName == "my-pvc" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a PVC volume named %q bound to claim %q", PvcSourceMountName, "my-pvc")
+	}
+}
+
+func TestInjectModelInitializerGcs(t *testing.T) {
+	podSpec := testPodSpec()
+	userContainer := &podSpec.Containers[0]
+
+	if err := InjectModelInitializer(podSpec, userContainer, "gs://my-bucket/my-model", "", "default", fakeConfigMapClient(), nil); err != nil {
+		t.Fatalf("unexpected error injecting model-initializer for GCS source: %v", err)
+	}
+
+	assertModelInitializerInjected(t, podSpec, userContainer)
+
+	for _, v := range podSpec.Volumes {
+		if v.Name == PvcSourceMountName {
+			t.Errorf("did not expect a PVC source volume for a GCS URI")
+		}
+	}
+}
+
+func TestInjectModelInitializerS3(t *testing.T) {
+	podSpec := testPodSpec()
+	userContainer := &podSpec.Containers[0]
+
+	if err := InjectModelInitializer(podSpec, userContainer, "s3://my-bucket/my-model", "", "default", fakeConfigMapClient(), nil); err != nil {
+		t.Fatalf("unexpected error injecting model-initializer for S3 source: %v", err)
+	}
+
+	assertModelInitializerInjected(t, podSpec, userContainer)
+}
+
+func TestInjectModelInitializerSkipsWhenAlreadyPresent(t *testing.T) {
+	podSpec := testPodSpec()
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{Name: ModelInitializerContainerName})
+	userContainer := &podSpec.Containers[0]
+
+	if err := InjectModelInitializer(podSpec, userContainer, "s3://my-bucket/my-model", "", "default", fakeConfigMapClient(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(podSpec.InitContainers) != 1 {
+		t.Errorf("expected the existing init container not to be duplicated, got %d init containers", len(podSpec.InitContainers))
+	}
+}
+
+func TestInjectModelInitializerDirectMountReadOnlyWithSubPath(t *testing.T) {
+	podSpec := testPodSpec()
+	userContainer := &podSpec.Containers[0]
+
+	options := &InjectorOptions{DirectVolumeMount: true, ReadOnly: true}
+	if err := InjectModelInitializer(podSpec, userContainer, "pvc://my-pvc/models/my-model", "", "default", fakeConfigMapClient(), options); err != nil {
+		t.Fatalf("unexpected error injecting direct PVC mount: %v", err)
+	}
+
+	assertDirectPvcMount(t, podSpec, userContainer, "my-pvc", "models/my-model", true)
+}
+
+func TestInjectModelInitializerDirectMountWritableNoSubPath(t *testing.T) {
+	podSpec := testPodSpec()
+	userContainer := &podSpec.Containers[0]
+
+	options := &InjectorOptions{DirectVolumeMount: true, ReadOnly: false}
+	if err := InjectModelInitializer(podSpec, userContainer, "pvc://my-pvc", "", "default", fakeConfigMapClient(), options); err != nil {
+		t.Fatalf("unexpected error injecting direct PVC mount: %v", err)
+	}
+
+	assertDirectPvcMount(t, podSpec, userContainer, "my-pvc", "", false)
+}
+
+func assertDirectPvcMount(t *testing.T, podSpec *corev1.PodSpec, userContainer *corev1.Container, pvcName string, subPath string, readOnly bool) {
+	t.Helper()
+
+	for _, c := range podSpec.InitContainers {
+		if c.Name == ModelInitializerContainerName {
+			t.Fatalf("did not expect a model-initializer init container in direct-mount mode")
+		}
+	}
+
+	var mount *corev1.VolumeMount
+	for i := range userContainer.VolumeMounts {
+		if userContainer.VolumeMounts[i].Name == ModelInitializerVolumeName {
+			mount = &userContainer.VolumeMounts[i]
+		}
+	}
+	if mount == nil {
+		t.Fatalf("expected the user container to have the PVC mounted directly at %q", DefaultModelLocalMountPath)
+	}
+	if mount.MountPath != DefaultModelLocalMountPath {
+		t.Errorf("expected mount path %q, got %q", DefaultModelLocalMountPath, mount.MountPath)
+	}
+	if mount.SubPath != subPath {
+		t.Errorf("expected SubPath %q, got %q", subPath, mount.SubPath)
+	}
+	if mount.ReadOnly != readOnly {
+		t.Errorf("expected ReadOnly=%v, got %v", readOnly, mount.ReadOnly)
+	}
+
+	var volume *corev1.Volume
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Name == ModelInitializerVolumeName {
+			volume = &podSpec.Volumes[i]
+		}
+	}
+	if volume == nil || volume.VolumeSource.PersistentVolumeClaim == nil {
+		t.Fatalf("expected a PVC volume named %q", ModelInitializerVolumeName)
+	}
+	if volume.VolumeSource.EmptyDir != nil {
+		t.Errorf("did not expect an emptyDir volume in direct-mount mode")
+	}
+	if volume.VolumeSource.PersistentVolumeClaim.ClaimName != pvcName {
+		t.Errorf("expected PVC claim %q, got %q", pvcName, volume.VolumeSource.PersistentVolumeClaim.ClaimName)
+	}
+	if volume.VolumeSource.PersistentVolumeClaim.ReadOnly != readOnly {
+		t.Errorf("expected PVC ReadOnly=%v, got %v", readOnly, volume.VolumeSource.PersistentVolumeClaim.ReadOnly)
+	}
+}
+
+func assertModelInitializerInjected(t *testing.T, podSpec *corev1.PodSpec, userContainer *corev1.Container) {
+	t.Helper()
+
+	found := false
+	for _, c := range podSpec.InitContainers {
+		if c.Name == ModelInitializerContainerName {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected an init container named %q to be injected", ModelInitializerContainerName)
+	}
+
+	mounted := false
+	for _, m := range userContainer.VolumeMounts {
+		if m.Name == ModelInitializerVolumeName && m.MountPath == DefaultModelLocalMountPath {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Fatalf("expected the user container to have the shared volume mounted at %q", DefaultModelLocalMountPath)
+	}
+}
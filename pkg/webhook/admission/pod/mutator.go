@@ -0,0 +1,123 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Mutator injects a model-initializer init container (and the credentials/volumes it needs)
+// into any Pod that carries the StorageInitializerSourceUriInternalAnnotationKey annotation.
+// Running this as a Pod admission webhook, rather than as part of the SeldonDeployment
+// controller's Deployment mutation, means any Pod-producing controller benefits from model
+// provisioning without the operator having to build the Pod itself.
+type Mutator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle implements admission.Handler.
+func (mutator *Mutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := mutator.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	srcURI, ok := pod.ObjectMeta.Annotations[StorageInitializerSourceUriInternalAnnotationKey]
+	if !ok {
+		return admission.Allowed("no storage-uri annotation found")
+	}
+
+	userContainer := findUserContainer(&pod.Spec, pod.ObjectMeta.Annotations)
+	if userContainer == nil {
+		log.Info("no target container found for model-initializer injection; name a container "+UserContainerName+" or set the "+UserContainerAnnotationKey+" annotation", "namespace", pod.Namespace, "name", pod.Name)
+		return admission.Allowed("no target container found for model-initializer injection")
+	}
+
+	serviceAccountName := pod.ObjectMeta.Annotations[StorageInitializerServiceAccountAnnotationKey]
+	options := injectorOptionsFromAnnotations(pod.ObjectMeta.Annotations)
+
+	if err := InjectModelInitializer(&pod.Spec, userContainer, srcURI, serviceAccountName, pod.Namespace, mutator.Client, options); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// InjectDecoder injects the decoder. InjectDecoder is called automatically by the controller-runtime manager.
+func (mutator *Mutator) InjectDecoder(decoder *admission.Decoder) error {
+	mutator.decoder = decoder
+	return nil
+}
+
+// injectorOptionsFromAnnotations reads the direct-mount opt-in and readOnly override off the
+// Pod's annotations. ReadOnly defaults to true when the annotation is absent or unparseable.
+func injectorOptionsFromAnnotations(annotations map[string]string) *InjectorOptions {
+	readOnly := true
+	if v, ok := annotations[StorageReadonlyAnnotationKey]; ok {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			readOnly = parsed
+		}
+	}
+
+	return &InjectorOptions{
+		DirectVolumeMount: annotations[StorageDirectMountAnnotationKey] == "true",
+		ReadOnly:          readOnly,
+	}
+}
+
+// UserContainerAnnotationKey names the container the model-initializer should provision into,
+// for Pods produced by controllers that don't follow the user-container naming convention (Job,
+// StatefulSet, Argo Workflow, ...). It takes precedence over both the UserContainerName
+// convention and the single-container fallback below.
+const UserContainerAnnotationKey = "serving.seldon.io/user-container"
+
+// findUserContainer picks the container model-initializer should provision into: the one named
+// by UserContainerAnnotationKey if set, else the conventionally-named UserContainerName, else -
+// if the Pod has exactly one container - that container. It returns nil if none of those apply,
+// which callers must treat as "nothing to inject", not as an error.
+func findUserContainer(podSpec *corev1.PodSpec, annotations map[string]string) *corev1.Container {
+	if name, ok := annotations[UserContainerAnnotationKey]; ok {
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == name {
+				return &podSpec.Containers[i]
+			}
+		}
+		return nil
+	}
+
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == UserContainerName {
+			return &podSpec.Containers[i]
+		}
+	}
+
+	if len(podSpec.Containers) == 1 {
+		return &podSpec.Containers[0]
+	}
+
+	return nil
+}
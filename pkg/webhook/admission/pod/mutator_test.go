@@ -0,0 +1,81 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFindUserContainerByConvention(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "sidecar"},
+			{Name: UserContainerName},
+		},
+	}
+
+	found := findUserContainer(podSpec, nil)
+	if found == nil || found.Name != UserContainerName {
+		t.Fatalf("expected the conventionally named container to be found, got %+v", found)
+	}
+}
+
+func TestFindUserContainerByAnnotation(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "classifier"},
+			{Name: "sidecar"},
+		},
+	}
+
+	found := findUserContainer(podSpec, map[string]string{UserContainerAnnotationKey: "classifier"})
+	if found == nil || found.Name != "classifier" {
+		t.Fatalf("expected the annotated container to be found, got %+v", found)
+	}
+}
+
+func TestFindUserContainerAnnotationMismatchIsNotFound(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "classifier"}},
+	}
+
+	found := findUserContainer(podSpec, map[string]string{UserContainerAnnotationKey: "does-not-exist"})
+	if found != nil {
+		t.Fatalf("expected no container to be found for a mismatched annotation, got %+v", found)
+	}
+}
+
+func TestFindUserContainerSingleContainerFallback(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "my-job"}},
+	}
+
+	found := findUserContainer(podSpec, nil)
+	if found == nil || found.Name != "my-job" {
+		t.Fatalf("expected the sole container to be used as a fallback, got %+v", found)
+	}
+}
+
+func TestFindUserContainerAmbiguousMultiContainerReturnsNil(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "a"}, {Name: "b"}},
+	}
+
+	found := findUserContainer(podSpec, nil)
+	if found != nil {
+		t.Fatalf("expected no container to be chosen without a convention match or annotation, got %+v", found)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProvisionersConfigMapKeyName is the seldon-config ConfigMap key holding the JSON-encoded
+// ProvisionerRegistry, keyed by URI scheme (e.g. "s3", "gs", "pvc", "oci").
+const ProvisionersConfigMapKeyName = "storageInitializers"
+
+// Provisioner describes the init container used to provision a model for one URI scheme.
+type Provisioner struct {
+	Image     string                      `json:"image"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	Env       []corev1.EnvVar             `json:"env,omitempty"`
+	// Args are appended after the default [srcURI, destPath] arguments, for provisioners that
+	// take extra flags (resumable transfers, auth modes the default image doesn't support, ...).
+	Args []string `json:"args,omitempty"`
+}
+
+// ProvisionerRegistry maps a URI scheme (without "://") to the Provisioner that should be used
+// to provision URIs of that scheme. It lets operators swap in an alternative downloader per
+// scheme - or tune CPU/memory for very large model downloads - without recompiling the operator.
+type ProvisionerRegistry map[string]Provisioner
+
+// defaultProvisioner is used for any scheme with no matching registry entry, preserving the
+// historical hard-coded image and version.
+func defaultProvisioner() Provisioner {
+	return Provisioner{
+		Image: ModelInitializerContainerImage + ":" + ModelInitializerContainerVersion,
+	}
+}
+
+// lookup returns the Provisioner registered for srcURI's scheme, or the default provisioner if
+// none is registered.
+func (registry ProvisionerRegistry) lookup(srcURI string) Provisioner {
+	scheme := uriScheme(srcURI)
+	if provisioner, ok := registry[scheme]; ok {
+		return provisioner
+	}
+	return defaultProvisioner()
+}
+
+func uriScheme(srcURI string) string {
+	idx := strings.Index(srcURI, "://")
+	if idx < 0 {
+		return ""
+	}
+	return srcURI[:idx]
+}
+
+// provisionerRegistryFromConfigMap decodes the ProvisionerRegistry stored under
+// ProvisionersConfigMapKeyName. A missing key is not an error: it just yields an empty registry,
+// so every scheme falls back to the default provisioner.
+func provisionerRegistryFromConfigMap(configMap *corev1.ConfigMap) (ProvisionerRegistry, error) {
+	registry := ProvisionerRegistry{}
+
+	raw, ok := configMap.Data[ProvisionersConfigMapKeyName]
+	if !ok || raw == "" {
+		return registry, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &registry); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s config map key %s: %v", configMap.Name, ProvisionersConfigMapKeyName, err)
+	}
+
+	return registry, nil
+}
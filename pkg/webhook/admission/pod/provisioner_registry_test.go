@@ -0,0 +1,101 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestProvisionerRegistryFromConfigMapMissingKey(t *testing.T) {
+	registry, err := provisionerRegistryFromConfigMap(&corev1.ConfigMap{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(registry) != 0 {
+		t.Errorf("expected an empty registry when the config map has no %s key, got %v", ProvisionersConfigMapKeyName, registry)
+	}
+}
+
+func TestProvisionerRegistryFromConfigMapParsesEntries(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		Data: map[string]string{
+			ProvisionersConfigMapKeyName: `{
+				"s3": {"image": "myrepo/s3-initializer:v1", "args": ["--resumable"]},
+				"oci": {"image": "myrepo/oci-puller:v1"}
+			}`,
+		},
+	}
+
+	registry, err := provisionerRegistryFromConfigMap(configMap)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s3, ok := registry["s3"]
+	if !ok || s3.Image != "myrepo/s3-initializer:v1" || len(s3.Args) != 1 || s3.Args[0] != "--resumable" {
+		t.Errorf("unexpected s3 entry: %+v", s3)
+	}
+	if _, ok := registry["oci"]; !ok {
+		t.Errorf("expected an oci entry to be parsed")
+	}
+}
+
+func TestProvisionerRegistryFromConfigMapInvalidJSON(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		Data: map[string]string{ProvisionersConfigMapKeyName: "not-json"},
+	}
+
+	if _, err := provisionerRegistryFromConfigMap(configMap); err == nil {
+		t.Errorf("expected an error parsing invalid JSON")
+	}
+}
+
+func TestProvisionerRegistryLookupFallsBackToDefault(t *testing.T) {
+	registry := ProvisionerRegistry{}
+
+	provisioner := registry.lookup("s3://my-bucket/my-model")
+	if provisioner.Image != ModelInitializerContainerImage+":"+ModelInitializerContainerVersion {
+		t.Errorf("expected the default image for an unregistered scheme, got %q", provisioner.Image)
+	}
+}
+
+func TestProvisionerRegistryLookupMatchesScheme(t *testing.T) {
+	registry := ProvisionerRegistry{
+		"gs": Provisioner{
+			Image:     "myrepo/gcs-initializer:v2",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}},
+		},
+	}
+
+	provisioner := registry.lookup("gs://my-bucket/my-model")
+	if provisioner.Image != "myrepo/gcs-initializer:v2" {
+		t.Errorf("expected the registered gs provisioner, got %+v", provisioner)
+	}
+}
+
+func TestUriScheme(t *testing.T) {
+	cases := map[string]string{
+		"s3://bucket/path": "s3",
+		"pvc://name/path":  "pvc",
+		"no-scheme-uri":    "",
+	}
+	for uri, want := range cases {
+		if got := uriScheme(uri); got != want {
+			t.Errorf("uriScheme(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
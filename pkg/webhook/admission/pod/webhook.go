@@ -0,0 +1,34 @@
+/*
+Copyright 2019 kubeflow.org.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+	http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// WebhookName is the path the Pod mutating webhook is served on, and is used to build the
+// MutatingWebhookConfiguration's clientConfig.service.path.
+const WebhookName = "/mutate-pod"
+
+// AddToManager registers the model-initializer Pod mutating webhook with mgr's webhook server.
+func AddToManager(mgr manager.Manager) error {
+	mgr.GetWebhookServer().Register(WebhookName, &webhook.Admission{
+		Handler: &Mutator{Client: mgr.GetClient()},
+	})
+	return nil
+}
+
+var _ admission.DecoderInjector = &Mutator{}